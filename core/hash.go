@@ -0,0 +1,110 @@
+package core
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+)
+
+// HashAlgorithm identifies the hash function a repository's object names
+// are computed with. Git repositories are SHA1 by default, with SHA256
+// available as an on-disk extension (extensions.objectFormat = sha256).
+type HashAlgorithm int8
+
+const (
+	SHA1 HashAlgorithm = iota
+	SHA256
+)
+
+// Size returns the digest size, in bytes, produced by the algorithm.
+func (a HashAlgorithm) Size() int {
+	switch a {
+	case SHA256:
+		return sha256.Size
+	default:
+		return sha1.Size
+	}
+}
+
+func (a HashAlgorithm) String() string {
+	switch a {
+	case SHA256:
+		return "sha256"
+	default:
+		return "sha1"
+	}
+}
+
+func newHasher(a HashAlgorithm) hash.Hash {
+	if a == SHA256 {
+		return sha256.New()
+	}
+	return sha1.New()
+}
+
+// Hash is a git object name. Its digest is either 20 bytes (SHA1) or 32
+// bytes (SHA256); only Algorithm().Size() bytes of the backing array are
+// meaningful.
+type Hash struct {
+	algo HashAlgorithm
+	b    [sha256.Size]byte
+}
+
+// Algorithm returns the hash function this digest was computed with.
+func (h Hash) Algorithm() HashAlgorithm { return h.algo }
+
+// String returns the lowercase hex encoding of the digest.
+func (h Hash) String() string {
+	return hex.EncodeToString(h.b[:h.algo.Size()])
+}
+
+// IsZero reports whether h is the zero value, i.e. no object name was ever
+// assigned to it.
+func (h Hash) IsZero() bool {
+	var empty [sha256.Size]byte
+	return h.b == empty
+}
+
+// NewHash parses a hex-encoded object name, inferring its HashAlgorithm
+// from its length: 40 hex characters for SHA1, 64 for SHA256.
+func NewHash(s string) (Hash, error) {
+	var algo HashAlgorithm
+	switch len(s) {
+	case sha1.Size * 2:
+		algo = SHA1
+	case sha256.Size * 2:
+		algo = SHA256
+	default:
+		return Hash{}, fmt.Errorf("core: invalid hash length %d", len(s))
+	}
+
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		return Hash{}, err
+	}
+
+	var h Hash
+	h.algo = algo
+	copy(h.b[:], raw)
+	return h, nil
+}
+
+// ComputeHash hashes content the way git names an object: the digest of
+// "<type> <size>\0<content>", under the given algorithm.
+func ComputeHash(algo HashAlgorithm, t ObjectType, content []byte) Hash {
+	hasher := newHasher(algo)
+	typ := t.String()
+	if t == TagObject {
+		// ObjectType.String has no name for tags; git's is "tag".
+		typ = "tag"
+	}
+	fmt.Fprintf(hasher, "%s %d\x00", typ, len(content))
+	hasher.Write(content)
+
+	var h Hash
+	h.algo = algo
+	copy(h.b[:], hasher.Sum(nil))
+	return h
+}
@@ -0,0 +1,72 @@
+package core
+
+import "testing"
+
+func TestHashAlgorithmSize(t *testing.T) {
+	if got := SHA1.Size(); got != 20 {
+		t.Errorf("SHA1.Size() = %d, want 20", got)
+	}
+	if got := SHA256.Size(); got != 32 {
+		t.Errorf("SHA256.Size() = %d, want 32", got)
+	}
+}
+
+func TestNewHashInfersAlgorithm(t *testing.T) {
+	sha1Hex := "da39a3ee5e6b4b0d3255bfef95601890afd80709"
+	sha256Hex := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"[:64]
+
+	h, err := NewHash(sha1Hex)
+	if err != nil {
+		t.Fatalf("NewHash(sha1Hex) error: %v", err)
+	}
+	if h.Algorithm() != SHA1 {
+		t.Errorf("NewHash(sha1Hex).Algorithm() = %v, want SHA1", h.Algorithm())
+	}
+	if h.String() != sha1Hex {
+		t.Errorf("NewHash(sha1Hex).String() = %q, want %q", h.String(), sha1Hex)
+	}
+
+	h, err = NewHash(sha256Hex)
+	if err != nil {
+		t.Fatalf("NewHash(sha256Hex) error: %v", err)
+	}
+	if h.Algorithm() != SHA256 {
+		t.Errorf("NewHash(sha256Hex).Algorithm() = %v, want SHA256", h.Algorithm())
+	}
+	if h.String() != sha256Hex {
+		t.Errorf("NewHash(sha256Hex).String() = %q, want %q", h.String(), sha256Hex)
+	}
+
+	if _, err := NewHash("not-a-hash"); err == nil {
+		t.Error("NewHash(\"not-a-hash\") error = nil, want non-nil")
+	}
+}
+
+func TestComputeHashPerAlgorithm(t *testing.T) {
+	content := []byte("hello")
+
+	h1 := ComputeHash(SHA1, BlobObject, content)
+	if h1.Algorithm() != SHA1 {
+		t.Errorf("ComputeHash(SHA1, ...).Algorithm() = %v, want SHA1", h1.Algorithm())
+	}
+	if len(h1.String()) != sha1HexLen {
+		t.Errorf("ComputeHash(SHA1, ...).String() length = %d, want %d", len(h1.String()), sha1HexLen)
+	}
+
+	h256 := ComputeHash(SHA256, BlobObject, content)
+	if h256.Algorithm() != SHA256 {
+		t.Errorf("ComputeHash(SHA256, ...).Algorithm() = %v, want SHA256", h256.Algorithm())
+	}
+	if len(h256.String()) != sha256HexLen {
+		t.Errorf("ComputeHash(SHA256, ...).String() length = %d, want %d", len(h256.String()), sha256HexLen)
+	}
+
+	if h1.String() == h256.String() {
+		t.Error("ComputeHash under SHA1 and SHA256 produced identical digests")
+	}
+}
+
+const (
+	sha1HexLen   = 40
+	sha256HexLen = 64
+)
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRUObjectStorage decorates any ObjectStorage with an in-memory LRU cache
+// of recently accessed objects. Unlike a typical LRU bounded by entry
+// count, it is bounded by the total decompressed size of the cached
+// objects, since git objects vary wildly in size and a handful of large
+// blobs can otherwise dominate a count-based cache. It is meant to sit in
+// front of a slower backend (a filesystem, packfile, or remote storage) so
+// repeated lookups of hot objects don't round-trip to it.
+type LRUObjectStorage struct {
+	backend  ObjectStorage
+	maxBytes int64
+
+	mu        sync.Mutex
+	usedBytes int64
+	ll        *list.List
+	items     map[Hash]*list.Element
+}
+
+type lruEntry struct {
+	hash Hash
+	obj  Object
+}
+
+// NewLRUObjectStorage returns an ObjectStorage that caches up to maxBytes
+// worth of decompressed object content read from or written to backend.
+// The most recently touched object is never evicted, so a single object
+// larger than maxBytes stays cached on its own.
+func NewLRUObjectStorage(backend ObjectStorage, maxBytes int64) *LRUObjectStorage {
+	return &LRUObjectStorage{
+		backend:  backend,
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[Hash]*list.Element),
+	}
+}
+
+func (c *LRUObjectStorage) New() Object {
+	return c.backend.New()
+}
+
+func (c *LRUObjectStorage) HashAlgorithm() HashAlgorithm {
+	return c.backend.HashAlgorithm()
+}
+
+func (c *LRUObjectStorage) Set(obj Object) Hash {
+	h := c.backend.Set(obj)
+	c.touch(h, obj)
+	return h
+}
+
+func (c *LRUObjectStorage) Get(h Hash) (Object, bool) {
+	c.mu.Lock()
+	if el, ok := c.items[h]; ok {
+		c.ll.MoveToFront(el)
+		obj := el.Value.(*lruEntry).obj
+		c.mu.Unlock()
+		return obj, true
+	}
+	c.mu.Unlock()
+
+	obj, ok := c.backend.Get(h)
+	if !ok {
+		return nil, false
+	}
+
+	c.touch(h, obj)
+	return obj, true
+}
+
+// Iter always falls through to the backend: caching an entire type's worth
+// of objects defeats the point of a size-bounded cache.
+func (c *LRUObjectStorage) Iter(t ObjectType) ObjectIter {
+	return c.backend.Iter(t)
+}
+
+func (c *LRUObjectStorage) touch(h Hash, obj Object) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[h]; ok {
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	el := c.ll.PushFront(&lruEntry{hash: h, obj: obj})
+	c.items[h] = el
+	c.usedBytes += obj.Size()
+
+	for c.usedBytes > c.maxBytes && c.ll.Len() > 1 {
+		c.evictOldest()
+	}
+}
+
+func (c *LRUObjectStorage) evictOldest() {
+	el := c.ll.Back()
+	if el == nil {
+		return
+	}
+
+	entry := el.Value.(*lruEntry)
+	c.ll.Remove(el)
+	delete(c.items, entry.hash)
+	c.usedBytes -= entry.obj.Size()
+}
@@ -0,0 +1,90 @@
+package core
+
+import "testing"
+
+func newBlob(content string) *RAWObject {
+	obj := &RAWObject{t: BlobObject, algo: SHA1}
+	obj.SetSize(int64(len(content)))
+	w := obj.Writer()
+	w.Write([]byte(content))
+	w.Close()
+	return obj
+}
+
+func TestLRUObjectStorageEvictsLeastRecentlyUsed(t *testing.T) {
+	backend := NewRAWObjectStorage()
+	cache := NewLRUObjectStorage(backend, 10)
+
+	a := newBlob("aaaaa")
+	b := newBlob("bbbbb")
+	c := newBlob("ccccc")
+
+	cache.Set(a)
+	cache.Set(b)
+
+	if got := len(cache.items); got != 2 {
+		t.Fatalf("cached items after setting a, b = %d, want 2", got)
+	}
+
+	// Writing c pushes usedBytes to 15, over the 10-byte budget: a, being
+	// the least recently touched, should be evicted to make room.
+	cache.Set(c)
+
+	if _, ok := cache.items[a.Hash()]; ok {
+		t.Error("a is still cached after c's insertion should have evicted it")
+	}
+	if _, ok := cache.items[b.Hash()]; !ok {
+		t.Error("b was evicted, want it to remain cached")
+	}
+	if _, ok := cache.items[c.Hash()]; !ok {
+		t.Error("c was not cached after Set")
+	}
+}
+
+func TestLRUObjectStorageTouchMovesToFront(t *testing.T) {
+	backend := NewRAWObjectStorage()
+	cache := NewLRUObjectStorage(backend, 10)
+
+	a := newBlob("aaaaa")
+	b := newBlob("bbbbb")
+	backend.Set(a)
+	backend.Set(b)
+
+	cache.Set(a)
+	cache.Set(b)
+
+	// Touching a moves it back to the front, so the next eviction should
+	// take b instead.
+	if _, ok := cache.Get(a.Hash()); !ok {
+		t.Fatal("Get(a) = false, want true")
+	}
+
+	c := newBlob("ccccc")
+	cache.Set(c)
+
+	if _, ok := cache.items[b.Hash()]; ok {
+		t.Error("b is still cached after c's insertion should have evicted it")
+	}
+	if _, ok := cache.items[a.Hash()]; !ok {
+		t.Error("a was evicted despite being touched most recently")
+	}
+}
+
+func TestLRUObjectStorageGetFallsBackToBackend(t *testing.T) {
+	backend := NewRAWObjectStorage()
+	cache := NewLRUObjectStorage(backend, 10)
+
+	a := newBlob("aaaaa")
+	backend.Set(a)
+
+	obj, ok := cache.Get(a.Hash())
+	if !ok {
+		t.Fatal("Get(a) = false, want true")
+	}
+	if obj.Hash() != a.Hash() {
+		t.Errorf("Get(a).Hash() = %v, want %v", obj.Hash(), a.Hash())
+	}
+	if _, ok := cache.items[a.Hash()]; !ok {
+		t.Error("Get did not populate the cache on a backend hit")
+	}
+}
@@ -3,6 +3,7 @@ package core
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 )
 
 // Object is a generic representation of any git object
@@ -12,8 +13,17 @@ type Object interface {
 	Size() int64
 	SetSize(int64)
 	Hash() Hash
-	Reader() io.Reader
-	Writer() io.Writer
+	// Reader returns a ReadCloser over the object's content. Callers must
+	// Close it once done, so that storage backends backed by an open file
+	// (packfiles, loose objects on disk) can release their handle.
+	Reader() io.ReadCloser
+	// Writer returns a WriteCloser that appends to the object's content.
+	// Close computes the object's hash once, over exactly the bytes that
+	// were written, and caches it, rather than leaving the first Hash()
+	// call to rehash the content (or worse, trusting a size set before
+	// the content was known). Reopening the writer invalidates the
+	// cached hash.
+	Writer() io.WriteCloser
 }
 
 // ObjectStorage generic storage of objects
@@ -22,6 +32,9 @@ type ObjectStorage interface {
 	Set(Object) Hash
 	Get(Hash) (Object, bool)
 	Iter(ObjectType) ObjectIter
+	// HashAlgorithm reports which HashAlgorithm object names passed to and
+	// returned from this storage are computed with.
+	HashAlgorithm() HashAlgorithm
 }
 
 // ObjectType internal object type's
@@ -139,45 +152,106 @@ func (iter *ObjectSliceIter) Close() {
 }
 
 type RAWObject struct {
-	b []byte
-	t ObjectType
-	s int64
+	b    []byte
+	t    ObjectType
+	s    int64
+	algo HashAlgorithm
+
+	h      Hash
+	hValid bool
 }
 
 func (o *RAWObject) Type() ObjectType     { return o.t }
 func (o *RAWObject) SetType(t ObjectType) { o.t = t }
 func (o *RAWObject) Size() int64          { return o.s }
 func (o *RAWObject) SetSize(s int64)      { o.s = s }
-func (o *RAWObject) Reader() io.Reader    { return bytes.NewBuffer(o.b) }
-func (o *RAWObject) Hash() Hash           { return ComputeHash(o.t, o.b) }
-func (o *RAWObject) Writer() io.Writer    { return o }
-func (o *RAWObject) Write(p []byte) (n int, err error) {
-	o.b = append(o.b, p...)
+
+func (o *RAWObject) Reader() io.ReadCloser {
+	return ioutil.NopCloser(bytes.NewReader(o.b))
+}
+
+// Hash returns the object's content hash, computing and caching it if the
+// writer hasn't already done so on Close.
+func (o *RAWObject) Hash() Hash {
+	if !o.hValid {
+		o.h = ComputeHash(o.algo, o.t, o.b)
+		o.hValid = true
+	}
+	return o.h
+}
+
+// Writer returns a WriteCloser that appends written bytes to the object.
+// The header a git object hash is computed over names the content's
+// length, which isn't known until writing is done, so Write just buffers;
+// Close is what sets the final size and hash, from all the content written
+// to the object so far (by this Writer and any earlier one) rather than
+// from whatever SetSize was last called with.
+func (o *RAWObject) Writer() io.WriteCloser {
+	o.hValid = false
+	return &rawObjectWriter{o: o}
+}
+
+type rawObjectWriter struct {
+	o *RAWObject
+}
+
+func (w *rawObjectWriter) Write(p []byte) (int, error) {
+	w.o.b = append(w.o.b, p...)
 	return len(p), nil
 }
 
+func (w *rawObjectWriter) Close() error {
+	w.o.s = int64(len(w.o.b))
+	w.o.h = ComputeHash(w.o.algo, w.o.t, w.o.b)
+	w.o.hValid = true
+	return nil
+}
+
 type RAWObjectStorage struct {
 	Objects map[Hash]Object
 	Commits map[Hash]Object
 	Trees   map[Hash]Object
 	Blobs   map[Hash]Object
+
+	algo HashAlgorithm
 }
 
+// NewRAWObjectStorage returns a RAWObjectStorage that names objects with
+// SHA1, git's default HashAlgorithm.
 func NewRAWObjectStorage() *RAWObjectStorage {
+	return NewRAWObjectStorageWithAlgorithm(SHA1)
+}
+
+// NewRAWObjectStorageWithAlgorithm returns a RAWObjectStorage that names
+// objects under the given HashAlgorithm, rejecting Set calls for objects
+// hashed under any other one.
+func NewRAWObjectStorageWithAlgorithm(algo HashAlgorithm) *RAWObjectStorage {
 	return &RAWObjectStorage{
 		Objects: make(map[Hash]Object, 0),
 		Commits: make(map[Hash]Object, 0),
 		Trees:   make(map[Hash]Object, 0),
 		Blobs:   make(map[Hash]Object, 0),
+		algo:    algo,
 	}
 }
 
 func (o *RAWObjectStorage) New() Object {
-	return &RAWObject{}
+	return &RAWObject{algo: o.algo}
+}
+
+func (o *RAWObjectStorage) HashAlgorithm() HashAlgorithm {
+	return o.algo
 }
 
+// Set installs obj under its hash and returns it. If obj was hashed under
+// a different HashAlgorithm than this storage was constructed with, Set
+// refuses it and returns the zero Hash instead.
 func (o *RAWObjectStorage) Set(obj Object) Hash {
 	h := obj.Hash()
+	if h.Algorithm() != o.algo {
+		return Hash{}
+	}
+
 	o.Objects[h] = obj
 
 	switch obj.Type() {
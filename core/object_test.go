@@ -0,0 +1,91 @@
+package core
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestRAWObjectWriterHashesIncrementallyOnClose(t *testing.T) {
+	obj := &RAWObject{t: BlobObject, algo: SHA1}
+	obj.SetSize(int64(len("hello")))
+
+	w := obj.Writer()
+	if _, err := w.Write([]byte("hel")); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	if _, err := w.Write([]byte("lo")); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	want := ComputeHash(SHA1, BlobObject, []byte("hello"))
+	if got := obj.Hash(); got != want {
+		t.Errorf("Hash() = %v, want %v", got, want)
+	}
+}
+
+func TestRAWObjectWriterHashIgnoresWrongSetSize(t *testing.T) {
+	obj := &RAWObject{t: BlobObject, algo: SHA1}
+	obj.SetSize(0) // wrong on purpose: content hasn't been produced yet
+
+	w := obj.Writer()
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	want := ComputeHash(SHA1, BlobObject, []byte("hello"))
+	if got := obj.Hash(); got != want {
+		t.Errorf("Hash() = %v, want %v (a stale SetSize must not poison the cached hash)", got, want)
+	}
+	if got := obj.Size(); got != int64(len("hello")) {
+		t.Errorf("Size() = %d, want %d", got, len("hello"))
+	}
+}
+
+func TestRAWObjectReaderReturnsWrittenContent(t *testing.T) {
+	obj := &RAWObject{t: BlobObject, algo: SHA1}
+	obj.SetSize(int64(len("hello")))
+
+	w := obj.Writer()
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	r := obj.Reader()
+	defer r.Close()
+
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll() error: %v", err)
+	}
+	if !bytes.Equal(got, []byte("hello")) {
+		t.Errorf("Reader() content = %q, want %q", got, "hello")
+	}
+}
+
+func TestRAWObjectWriterReopenInvalidatesHash(t *testing.T) {
+	obj := &RAWObject{t: BlobObject, algo: SHA1}
+	obj.SetSize(int64(len("hello")))
+
+	w := obj.Writer()
+	w.Write([]byte("hello"))
+	w.Close()
+
+	if !obj.hValid {
+		t.Fatal("hValid = false after Writer().Close(), want true")
+	}
+
+	obj.Writer()
+	if obj.hValid {
+		t.Error("hValid = true right after calling Writer() again, want false until the next Close")
+	}
+}
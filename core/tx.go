@@ -0,0 +1,91 @@
+package core
+
+import "fmt"
+
+// Tx is a staging area for a batch of object writes against an
+// ObjectStorage. Objects set on a Tx are not visible through the
+// originating storage's Get/Iter until Commit succeeds; Rollback discards
+// them instead. This lets a caller that is pushing a pack stage every
+// object it contains and then either install all of them atomically or
+// discard the lot on failure.
+type Tx interface {
+	Set(Object) Hash
+	Get(Hash) (Object, bool)
+	Commit() error
+	Rollback() error
+}
+
+// TransactionalObjectStorage is implemented by ObjectStorage backends that
+// support staging writes via Begin, instead of installing every Set call
+// immediately.
+type TransactionalObjectStorage interface {
+	ObjectStorage
+	Begin() Tx
+}
+
+// rawTx is the Tx returned by RAWObjectStorage.Begin. Since
+// RAWObjectStorage already lives entirely in memory, staging just means
+// keeping writes out of the backing maps until Commit.
+type rawTx struct {
+	storage *RAWObjectStorage
+	pending map[Hash]Object
+	done    bool
+	err     error
+}
+
+// Begin returns a Tx that stages Set calls in memory and installs them into
+// the storage's maps on Commit.
+func (o *RAWObjectStorage) Begin() Tx {
+	return &rawTx{storage: o, pending: make(map[Hash]Object)}
+}
+
+// Set stages obj, unless its hash was computed under a different
+// HashAlgorithm than the storage this Tx belongs to, which RAWObjectStorage
+// would refuse on Commit anyway. Rather than dropping obj silently, that
+// case is remembered and reported as an error by Commit, so a batch the
+// storage can't actually hold is discarded in full instead of partially
+// installed.
+func (tx *rawTx) Set(obj Object) Hash {
+	h := obj.Hash()
+	if h.Algorithm() != tx.storage.algo {
+		if tx.err == nil {
+			tx.err = fmt.Errorf("core: staged object %s was hashed with %s, storage uses %s", h, h.Algorithm(), tx.storage.algo)
+		}
+		return Hash{}
+	}
+
+	tx.pending[h] = obj
+	return h
+}
+
+func (tx *rawTx) Get(h Hash) (Object, bool) {
+	if obj, ok := tx.pending[h]; ok {
+		return obj, true
+	}
+	return tx.storage.Get(h)
+}
+
+// Commit installs every staged object, or none of them: if Set earlier
+// rejected an object, it returns that error instead of installing the rest
+// and leaving the rejected object's absence unexplained.
+func (tx *rawTx) Commit() error {
+	if tx.done {
+		return tx.err
+	}
+	tx.done = true
+
+	if tx.err != nil {
+		return tx.err
+	}
+
+	for _, obj := range tx.pending {
+		tx.storage.Set(obj)
+	}
+	return nil
+}
+
+func (tx *rawTx) Rollback() error {
+	tx.done = true
+	tx.pending = nil
+	return nil
+}
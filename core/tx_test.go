@@ -0,0 +1,60 @@
+package core
+
+import "testing"
+
+func TestRawTxCommitInstallsStagedObjects(t *testing.T) {
+	storage := NewRAWObjectStorage()
+	a := newBlob("aaaaa")
+
+	tx := storage.Begin()
+	tx.Set(a)
+
+	if _, ok := storage.Get(a.Hash()); ok {
+		t.Fatal("a is visible through the storage before Commit")
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() error: %v", err)
+	}
+
+	if _, ok := storage.Get(a.Hash()); !ok {
+		t.Error("a is not visible through the storage after Commit")
+	}
+}
+
+func TestRawTxCommitRejectsMismatchedAlgorithm(t *testing.T) {
+	storage := NewRAWObjectStorageWithAlgorithm(SHA1)
+
+	obj := &RAWObject{t: BlobObject, algo: SHA256}
+	obj.SetSize(5)
+	w := obj.Writer()
+	w.Write([]byte("aaaaa"))
+	w.Close()
+
+	tx := storage.Begin()
+	tx.Set(obj)
+
+	if err := tx.Commit(); err == nil {
+		t.Fatal("Commit() error = nil, want an error for a staged object hashed under the wrong algorithm")
+	}
+
+	if _, ok := storage.Get(obj.Hash()); ok {
+		t.Error("a rejected object is visible through the storage after Commit")
+	}
+}
+
+func TestRawTxRollbackDiscardsStagedObjects(t *testing.T) {
+	storage := NewRAWObjectStorage()
+	a := newBlob("aaaaa")
+
+	tx := storage.Begin()
+	tx.Set(a)
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback() error: %v", err)
+	}
+
+	if _, ok := storage.Get(a.Hash()); ok {
+		t.Error("a is visible through the storage after Rollback")
+	}
+}
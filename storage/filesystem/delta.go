@@ -0,0 +1,131 @@
+package filesystem
+
+import "fmt"
+
+// applyDelta reconstructs an object's content by replaying a git delta
+// (as found in OFS_DELTA/REF_DELTA pack entries) against its base content.
+//
+// A delta is a source-size varint, a target-size varint, and a sequence of
+// copy ("take N bytes from the base starting at offset O") and insert
+// ("take the following N literal bytes") instructions.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	srcSize, delta, err := readDeltaSize(delta)
+	if err != nil {
+		return nil, err
+	}
+	if int(srcSize) != len(base) {
+		return nil, fmt.Errorf("filesystem: delta base size mismatch: want %d, got %d", srcSize, len(base))
+	}
+
+	targetSize, delta, err := readDeltaSize(delta)
+	if err != nil {
+		return nil, err
+	}
+
+	target := make([]byte, 0, targetSize)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		if op&0x80 != 0 {
+			var offset, size uint32
+			var b byte
+			var err error
+
+			if op&0x01 != 0 {
+				if b, delta, err = readDeltaByte(delta); err != nil {
+					return nil, err
+				}
+				offset = uint32(b)
+			}
+			if op&0x02 != 0 {
+				if b, delta, err = readDeltaByte(delta); err != nil {
+					return nil, err
+				}
+				offset |= uint32(b) << 8
+			}
+			if op&0x04 != 0 {
+				if b, delta, err = readDeltaByte(delta); err != nil {
+					return nil, err
+				}
+				offset |= uint32(b) << 16
+			}
+			if op&0x08 != 0 {
+				if b, delta, err = readDeltaByte(delta); err != nil {
+					return nil, err
+				}
+				offset |= uint32(b) << 24
+			}
+			if op&0x10 != 0 {
+				if b, delta, err = readDeltaByte(delta); err != nil {
+					return nil, err
+				}
+				size = uint32(b)
+			}
+			if op&0x20 != 0 {
+				if b, delta, err = readDeltaByte(delta); err != nil {
+					return nil, err
+				}
+				size |= uint32(b) << 8
+			}
+			if op&0x40 != 0 {
+				if b, delta, err = readDeltaByte(delta); err != nil {
+					return nil, err
+				}
+				size |= uint32(b) << 16
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+
+			if int(offset)+int(size) > len(base) {
+				return nil, fmt.Errorf("filesystem: delta copy out of range")
+			}
+			target = append(target, base[offset:offset+size]...)
+		} else if op != 0 {
+			size := int(op)
+			if size > len(delta) {
+				return nil, fmt.Errorf("filesystem: delta insert out of range")
+			}
+			target = append(target, delta[:size]...)
+			delta = delta[size:]
+		} else {
+			return nil, fmt.Errorf("filesystem: invalid delta opcode 0")
+		}
+	}
+
+	if len(target) != int(targetSize) {
+		return nil, fmt.Errorf("filesystem: delta target size mismatch: want %d, got %d", targetSize, len(target))
+	}
+
+	return target, nil
+}
+
+// readDeltaByte reads a single byte off the front of delta, used by the
+// copy-instruction's variable-length offset/size fields. Unlike a plain
+// delta[0] index, it reports truncation as an error instead of panicking on
+// corrupt or adversarial pack data.
+func readDeltaByte(delta []byte) (byte, []byte, error) {
+	if len(delta) == 0 {
+		return 0, nil, fmt.Errorf("filesystem: truncated delta")
+	}
+	return delta[0], delta[1:], nil
+}
+
+// readDeltaSize reads a delta-encoded size varint (7 bits per byte,
+// little-endian, high bit signals continuation) and returns the remaining
+// bytes of the delta.
+func readDeltaSize(delta []byte) (int64, []byte, error) {
+	var size int64
+	var shift uint
+
+	for i, b := range delta {
+		size |= int64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			return size, delta[i+1:], nil
+		}
+	}
+
+	return 0, nil, fmt.Errorf("filesystem: truncated delta size")
+}
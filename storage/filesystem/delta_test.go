@@ -0,0 +1,89 @@
+package filesystem
+
+import "testing"
+
+func TestApplyDeltaCopyAndInsert(t *testing.T) {
+	base := []byte("hello world")
+
+	// source size 11, target size 11: copy "hello" (offset 0, size 5),
+	// insert " ", copy "world" (offset 6, size 5).
+	delta := []byte{11, 11}
+	delta = append(delta, 0x80|0x01|0x10, 0, 5) // copy offset=0, size=5 -> "hello"
+	delta = append(delta, 1, ' ')               // insert " "
+	delta = append(delta, 0x80|0x01|0x10, 6, 5) // copy offset=6, size=5 -> "world"
+
+	got, err := applyDelta(base, delta)
+	if err != nil {
+		t.Fatalf("applyDelta() error: %v", err)
+	}
+	want := "hello world"
+	if string(got) != want {
+		t.Errorf("applyDelta() = %q, want %q", got, want)
+	}
+}
+
+func TestApplyDeltaBaseSizeMismatch(t *testing.T) {
+	base := []byte("hello")
+	delta := []byte{99, 0}
+
+	if _, err := applyDelta(base, delta); err == nil {
+		t.Error("applyDelta() error = nil, want a base size mismatch error")
+	}
+}
+
+func TestApplyDeltaCopyOutOfRange(t *testing.T) {
+	base := []byte("hello")
+	delta := []byte{5, 5}
+	delta = append(delta, 0x80|0x01|0x10, 0, 200) // copy offset=0, size=200: past base
+
+	if _, err := applyDelta(base, delta); err == nil {
+		t.Error("applyDelta() error = nil, want a delta copy out of range error")
+	}
+}
+
+func TestApplyDeltaTruncatedCopyInstructionDoesNotPanic(t *testing.T) {
+	base := []byte("hello")
+	// A copy opcode claiming an offset byte follows, but the delta ends
+	// right after the opcode: must be reported as an error, not index out
+	// of range.
+	delta := []byte{5, 5, 0x80 | 0x01}
+
+	if _, err := applyDelta(base, delta); err == nil {
+		t.Error("applyDelta() error = nil, want a truncated delta error")
+	}
+}
+
+func TestApplyDeltaInsertOutOfRange(t *testing.T) {
+	base := []byte("hello")
+	delta := []byte{5, 5}
+	delta = append(delta, 10, 'a', 'b') // insert claims 10 literal bytes, only 2 follow
+
+	if _, err := applyDelta(base, delta); err == nil {
+		t.Error("applyDelta() error = nil, want a delta insert out of range error")
+	}
+}
+
+func TestReadDeltaSize(t *testing.T) {
+	// 300 encoded as a delta varint: 0xAC, 0x02 -> (0x2c) | (0x02 << 7) = 300
+	delta := []byte{0xAC, 0x02, 0xFF}
+
+	size, rest, err := readDeltaSize(delta)
+	if err != nil {
+		t.Fatalf("readDeltaSize() error: %v", err)
+	}
+	if size != 300 {
+		t.Errorf("readDeltaSize() size = %d, want 300", size)
+	}
+	if len(rest) != 1 || rest[0] != 0xFF {
+		t.Errorf("readDeltaSize() rest = %v, want [0xFF]", rest)
+	}
+}
+
+func TestReadDeltaSizeTruncated(t *testing.T) {
+	// High bit set on every byte: continuation never terminates.
+	delta := []byte{0x80, 0x80}
+
+	if _, _, err := readDeltaSize(delta); err == nil {
+		t.Error("readDeltaSize() error = nil, want a truncated delta error")
+	}
+}
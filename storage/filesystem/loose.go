@@ -0,0 +1,219 @@
+package filesystem
+
+import (
+	"bufio"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/kimh/go-git/core"
+)
+
+// loosePath returns the on-disk path for a loose object: the first two hex
+// digits of the hash name a directory, the remaining digits name the file.
+func (s *ObjectStorage) loosePath(h core.Hash) string {
+	hex := h.String()
+	return filepath.Join(s.dir, hex[:2], hex[2:])
+}
+
+func (s *ObjectStorage) hasLoose(h core.Hash) bool {
+	_, err := os.Stat(s.loosePath(h))
+	return err == nil
+}
+
+// writeLoose zlib-compresses obj's "<type> <size>\0<content>" representation
+// and installs it atomically via a temp file + rename, matching how git
+// itself writes loose objects.
+func (s *ObjectStorage) writeLoose(h core.Hash, obj core.Object) error {
+	return writeLooseObjectAt(s.loosePath(h), obj)
+}
+
+// writeLooseObjectAt writes obj's loose-object representation directly to
+// path via a temp-file-plus-rename, without assuming path sits inside a
+// storage's own objects directory.
+func writeLooseObjectAt(path string, obj core.Object) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, "tmp_obj_")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	w := zlib.NewWriter(tmp)
+	if _, err := fmt.Fprintf(w, "%s %d\x00", typeName(obj.Type()), obj.Size()); err != nil {
+		w.Close()
+		tmp.Close()
+		return err
+	}
+	r := obj.Reader()
+	_, copyErr := io.Copy(w, r)
+	r.Close()
+	if copyErr != nil {
+		w.Close()
+		tmp.Close()
+		return copyErr
+	}
+	if err := w.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpName, path)
+}
+
+func (s *ObjectStorage) getLoose(h core.Hash) (core.Object, bool) {
+	return readLooseObjectAt(s.loosePath(h))
+}
+
+func readLooseObjectAt(path string) (core.Object, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		return nil, false
+	}
+	defer zr.Close()
+
+	br := bufio.NewReader(zr)
+	t, size, err := readLooseHeader(br)
+	if err != nil {
+		return nil, false
+	}
+
+	obj := &core.RAWObject{}
+	obj.SetType(t)
+	obj.SetSize(size)
+
+	w := obj.Writer()
+	_, copyErr := io.Copy(w, br)
+	closeErr := w.Close()
+	if copyErr != nil || closeErr != nil {
+		return nil, false
+	}
+
+	return obj, true
+}
+
+// looseHashesOfType scans the loose object directories, inflating just
+// enough of each to read its type header, and returns the hashes matching t.
+func (s *ObjectStorage) looseHashesOfType(t core.ObjectType) []core.Hash {
+	dirs, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil
+	}
+
+	var hashes []core.Hash
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+
+		files, err := ioutil.ReadDir(filepath.Join(s.dir, dir.Name()))
+		if err != nil {
+			continue
+		}
+
+		for _, file := range files {
+			hex := dir.Name() + file.Name()
+			h, err := core.NewHash(hex)
+			if err != nil {
+				continue
+			}
+
+			if s.looseObjectType(h) == t {
+				hashes = append(hashes, h)
+			}
+		}
+	}
+
+	return hashes
+}
+
+func (s *ObjectStorage) looseObjectType(h core.Hash) core.ObjectType {
+	f, err := os.Open(s.loosePath(h))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		return 0
+	}
+	defer zr.Close()
+
+	t, _, err := readLooseHeader(bufio.NewReader(zr))
+	if err != nil {
+		return 0
+	}
+	return t
+}
+
+// readLooseHeader reads the "<type> <size>\0" header that precedes the
+// content of a loose object.
+func readLooseHeader(br *bufio.Reader) (core.ObjectType, int64, error) {
+	typeStr, err := br.ReadString(' ')
+	if err != nil {
+		return 0, 0, err
+	}
+	typeStr = typeStr[:len(typeStr)-1]
+
+	sizeStr, err := br.ReadString(0)
+	if err != nil {
+		return 0, 0, err
+	}
+	sizeStr = sizeStr[:len(sizeStr)-1]
+
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return parseTypeName(typeStr), size, nil
+}
+
+func typeName(t core.ObjectType) string {
+	switch t {
+	case core.CommitObject:
+		return "commit"
+	case core.TreeObject:
+		return "tree"
+	case core.BlobObject:
+		return "blob"
+	case core.TagObject:
+		return "tag"
+	default:
+		return "-"
+	}
+}
+
+func parseTypeName(name string) core.ObjectType {
+	switch name {
+	case "commit":
+		return core.CommitObject
+	case "tree":
+		return core.TreeObject
+	case "blob":
+		return core.BlobObject
+	case "tag":
+		return core.TagObject
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,345 @@
+package filesystem
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/kimh/go-git/core"
+)
+
+// resolver looks up an object hash across every pack known to the storage,
+// used to resolve REF_DELTA bases that live outside the pack currently
+// being read.
+type resolver func(core.Hash) (core.Object, bool)
+
+// packfile pairs a .pack file with its .idx and can resolve individual
+// objects, including OFS_DELTA/REF_DELTA chains, without loading the
+// whole pack into memory.
+type packfile struct {
+	dataPath string
+	idx      *packIndex
+	resolve  resolver
+}
+
+func openPackfile(idxPath string, resolve resolver) (*packfile, error) {
+	idx, err := readPackIndex(idxPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &packfile{
+		dataPath: strings.TrimSuffix(idxPath, ".idx") + ".pack",
+		idx:      idx,
+		resolve:  resolve,
+	}, nil
+}
+
+func (p *packfile) get(h core.Hash) (core.ObjectType, []byte, bool) {
+	offset, ok := p.idx.find(h)
+	if !ok {
+		return 0, nil, false
+	}
+
+	f, err := os.Open(p.dataPath)
+	if err != nil {
+		return 0, nil, false
+	}
+	defer f.Close()
+
+	return p.readAt(f, offset)
+}
+
+func (p *packfile) hashesOfType(t core.ObjectType) []core.Hash {
+	f, err := os.Open(p.dataPath)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var hashes []core.Hash
+	for _, h := range p.idx.hashes {
+		offset, _ := p.idx.find(h)
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			continue
+		}
+		entryType, _, err := readPackObjectHeader(f)
+		if err != nil {
+			continue
+		}
+		if resolvedType(entryType) == 0 {
+			var ok bool
+			if entryType, _, ok = p.readAt(f, offset); !ok {
+				continue
+			}
+		}
+		if entryType == t {
+			hashes = append(hashes, h)
+		}
+	}
+	return hashes
+}
+
+// resolvedType collapses the delta object types to "unknown" (0): a
+// delta's real type is only known after its base chain has been resolved,
+// which hashesOfType then does through readAt.
+func resolvedType(t core.ObjectType) core.ObjectType {
+	switch t {
+	case core.OFSDeltaObject, core.REFDeltaObject:
+		return 0
+	default:
+		return t
+	}
+}
+
+// readAt decodes the object stored at offset, recursively resolving any
+// delta chain, and returns its final type and content.
+func (p *packfile) readAt(f *os.File, offset int64) (core.ObjectType, []byte, bool) {
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, nil, false
+	}
+
+	t, size, err := readPackObjectHeader(f)
+	if err != nil {
+		return 0, nil, false
+	}
+
+	switch t {
+	case core.OFSDeltaObject:
+		relOffset, err := readOffsetDeltaBase(f)
+		if err != nil {
+			return 0, nil, false
+		}
+
+		// The delta data follows its header, so read it before recursing
+		// into the base moves f elsewhere.
+		delta, err := inflate(f, size)
+		if err != nil {
+			return 0, nil, false
+		}
+
+		baseType, baseContent, ok := p.readAt(f, offset-relOffset)
+		if !ok {
+			return 0, nil, false
+		}
+
+		content, err := applyDelta(baseContent, delta)
+		if err != nil {
+			return 0, nil, false
+		}
+		return baseType, content, true
+
+	case core.REFDeltaObject:
+		var raw [20]byte
+		if _, err := io.ReadFull(f, raw[:]); err != nil {
+			return 0, nil, false
+		}
+		baseHash, err := core.NewHash(fmt.Sprintf("%x", raw))
+		if err != nil {
+			return 0, nil, false
+		}
+
+		baseType, baseContent, ok := p.resolveBase(baseHash)
+		if !ok {
+			return 0, nil, false
+		}
+
+		delta, err := inflate(f, size)
+		if err != nil {
+			return 0, nil, false
+		}
+
+		content, err := applyDelta(baseContent, delta)
+		if err != nil {
+			return 0, nil, false
+		}
+		return baseType, content, true
+
+	default:
+		content, err := inflate(f, size)
+		if err != nil {
+			return 0, nil, false
+		}
+		return t, content, true
+	}
+}
+
+func (p *packfile) resolveBase(h core.Hash) (core.ObjectType, []byte, bool) {
+	if t, content, ok := p.get(h); ok {
+		return t, content, true
+	}
+
+	if p.resolve == nil {
+		return 0, nil, false
+	}
+
+	obj, ok := p.resolve(h)
+	if !ok {
+		return 0, nil, false
+	}
+
+	r := obj.Reader()
+	content, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return 0, nil, false
+	}
+	return obj.Type(), content, true
+}
+
+func inflate(f *os.File, size int64) ([]byte, error) {
+	zr, err := zlib.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	content := make([]byte, size)
+	if _, err := io.ReadFull(zr, content); err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return content, nil
+}
+
+// readPackObjectHeader decodes the variable-length (type, size) header that
+// precedes every object in a packfile.
+func readPackObjectHeader(r io.Reader) (core.ObjectType, int64, error) {
+	var buf [1]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, 0, err
+	}
+
+	t := core.ObjectType((buf[0] >> 4) & 0x7)
+	size := int64(buf[0] & 0xf)
+	shift := uint(4)
+
+	for buf[0]&0x80 != 0 {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return 0, 0, err
+		}
+		size |= int64(buf[0]&0x7f) << shift
+		shift += 7
+	}
+
+	return t, size, nil
+}
+
+// readOffsetDeltaBase decodes the base-offset varint used by OFS_DELTA
+// entries: the distance, in bytes, to subtract from the delta's own offset
+// to find its base object.
+func readOffsetDeltaBase(r io.Reader) (int64, error) {
+	var buf [1]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+
+	offset := int64(buf[0] & 0x7f)
+	for buf[0]&0x80 != 0 {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return 0, err
+		}
+		offset = ((offset + 1) << 7) | int64(buf[0]&0x7f)
+	}
+
+	return offset, nil
+}
+
+// packIndex is the fully-parsed contents of a version 2 .idx file: for each
+// object it records its hash and its offset into the matching .pack file.
+type packIndex struct {
+	hashes  []core.Hash
+	offsets map[core.Hash]int64
+}
+
+var packIdxMagic = [4]byte{0xff, 't', 'O', 'c'}
+
+func readPackIndex(path string) (*packIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, err
+	}
+	if magic != packIdxMagic {
+		return nil, fmt.Errorf("filesystem: unsupported pack index format")
+	}
+
+	var version uint32
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return nil, err
+	}
+	if version != 2 {
+		return nil, fmt.Errorf("filesystem: unsupported pack index version %d", version)
+	}
+
+	var fanout [256]uint32
+	if err := binary.Read(r, binary.BigEndian, fanout[:]); err != nil {
+		return nil, err
+	}
+	count := int(fanout[255])
+
+	hashes := make([]core.Hash, count)
+	for i := 0; i < count; i++ {
+		var raw [20]byte
+		if _, err := io.ReadFull(r, raw[:]); err != nil {
+			return nil, err
+		}
+		h, err := core.NewHash(fmt.Sprintf("%x", raw))
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = h
+	}
+
+	// CRC32 checksums, one per object: not needed for lookups.
+	if _, err := io.CopyN(ioutil.Discard, r, int64(count)*4); err != nil {
+		return nil, err
+	}
+
+	offsets32 := make([]uint32, count)
+	if err := binary.Read(r, binary.BigEndian, offsets32); err != nil {
+		return nil, err
+	}
+
+	offsets := make(map[core.Hash]int64, count)
+	var large []uint32
+	for i, off := range offsets32 {
+		if off&0x80000000 != 0 {
+			large = append(large, off&0x7fffffff)
+			continue
+		}
+		offsets[hashes[i]] = int64(off)
+	}
+
+	if len(large) > 0 {
+		big := make([]uint64, len(large))
+		if err := binary.Read(r, binary.BigEndian, big); err != nil {
+			return nil, err
+		}
+		li := 0
+		for i, off := range offsets32 {
+			if off&0x80000000 != 0 {
+				offsets[hashes[i]] = int64(big[li])
+				li++
+			}
+		}
+	}
+
+	return &packIndex{hashes: hashes, offsets: offsets}, nil
+}
+
+func (idx *packIndex) find(h core.Hash) (int64, bool) {
+	off, ok := idx.offsets[h]
+	return off, ok
+}
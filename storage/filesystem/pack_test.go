@@ -0,0 +1,116 @@
+package filesystem
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kimh/go-git/core"
+)
+
+func TestReadPackObjectHeaderSingleByte(t *testing.T) {
+	// type=BlobObject(3), size=10: fits in the 4-bit size nibble, so no
+	// continuation byte is needed.
+	r := bytes.NewReader([]byte{byte(core.BlobObject)<<4 | 10})
+
+	typ, size, err := readPackObjectHeader(r)
+	if err != nil {
+		t.Fatalf("readPackObjectHeader() error: %v", err)
+	}
+	if typ != core.BlobObject {
+		t.Errorf("type = %v, want %v", typ, core.BlobObject)
+	}
+	if size != 10 {
+		t.Errorf("size = %d, want 10", size)
+	}
+}
+
+func TestReadPackObjectHeaderMultiByteSize(t *testing.T) {
+	// type=BlobObject(3), size=300: 300 = 0b100101100. Low 4 bits (1100)
+	// go in the first byte with the continuation bit set, the remaining
+	// bits follow 7 at a time.
+	first := byte(core.BlobObject)<<4 | 0x0c | 0x80
+	second := byte(300 >> 4)
+	r := bytes.NewReader([]byte{first, second})
+
+	typ, size, err := readPackObjectHeader(r)
+	if err != nil {
+		t.Fatalf("readPackObjectHeader() error: %v", err)
+	}
+	if typ != core.BlobObject {
+		t.Errorf("type = %v, want %v", typ, core.BlobObject)
+	}
+	if size != 300 {
+		t.Errorf("size = %d, want 300", size)
+	}
+}
+
+func TestReadOffsetDeltaBase(t *testing.T) {
+	r := bytes.NewReader([]byte{0x7f})
+
+	off, err := readOffsetDeltaBase(r)
+	if err != nil {
+		t.Fatalf("readOffsetDeltaBase() error: %v", err)
+	}
+	if off != 0x7f {
+		t.Errorf("offset = %d, want %d", off, 0x7f)
+	}
+}
+
+func testHash(t *testing.T, suffix byte) core.Hash {
+	t.Helper()
+	raw := make([]byte, 20)
+	raw[19] = suffix
+	h, err := core.NewHash(hexEncode(raw))
+	if err != nil {
+		t.Fatalf("NewHash() error: %v", err)
+	}
+	return h
+}
+
+func hexEncode(raw []byte) string {
+	const digits = "0123456789abcdef"
+	out := make([]byte, len(raw)*2)
+	for i, b := range raw {
+		out[i*2] = digits[b>>4]
+		out[i*2+1] = digits[b&0xf]
+	}
+	return string(out)
+}
+
+func TestPackIndexRoundTripWithLargeOffsets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gogit-packidx-test")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	small := testHash(t, 1)
+	large := testHash(t, 2)
+
+	offsets := map[core.Hash]int64{
+		small: 1234,
+		// Past the 31-bit offset a single uint32 can hold: must round-trip
+		// through the idx large-offset table.
+		large: 0x180000000,
+	}
+
+	idxPath := filepath.Join(dir, "pack-test.idx")
+	if err := writePackIndex(idxPath, offsets); err != nil {
+		t.Fatalf("writePackIndex() error: %v", err)
+	}
+
+	idx, err := readPackIndex(idxPath)
+	if err != nil {
+		t.Fatalf("readPackIndex() error: %v", err)
+	}
+
+	if off, ok := idx.find(small); !ok || off != 1234 {
+		t.Errorf("find(small) = (%d, %v), want (1234, true)", off, ok)
+	}
+	if off, ok := idx.find(large); !ok || off != 0x180000000 {
+		t.Errorf("find(large) = (%d, %v), want (%d, true)", off, ok, int64(0x180000000))
+	}
+}
@@ -0,0 +1,182 @@
+// Package filesystem implements a core.ObjectStorage backed by a real
+// .git/objects directory: zlib-compressed loose objects under
+// objects/xx/yyyy... and packfile/index pairs under objects/pack/.
+package filesystem
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+
+	"github.com/kimh/go-git/core"
+)
+
+// repackThreshold is the number of loose objects written before Set
+// triggers an automatic repack, mirroring git's gc.auto behaviour.
+const repackThreshold = 256
+
+// ObjectStorage is a disk-backed core.ObjectStorage. It keeps objects in
+// the standard git on-disk layout: newly written objects are stored loose,
+// and Repack folds accumulated loose objects into a pack + index pair so
+// that lookups stay fast as a repository grows past what fits in RAM.
+type ObjectStorage struct {
+	dir string
+
+	mu    sync.RWMutex
+	packs []*packfile
+
+	looseCount int
+
+	// repackMu serializes Repack so that two Set calls crossing
+	// repackThreshold at once can't each build a pack at the same
+	// hash-derived path and interleave writes to it.
+	repackMu sync.Mutex
+}
+
+// NewObjectStorage returns an ObjectStorage rooted at dir, which is
+// typically the "objects" directory of a .git folder. dir and its
+// "pack" subdirectory are created lazily on first write.
+func NewObjectStorage(dir string) *ObjectStorage {
+	s := &ObjectStorage{dir: dir}
+	s.loadPacks()
+	return s
+}
+
+func (s *ObjectStorage) loadPacks() {
+	entries, err := ioutil.ReadDir(filepath.Join(s.dir, "pack"))
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ".idx" {
+			continue
+		}
+
+		idxPath := filepath.Join(s.dir, "pack", entry.Name())
+		p, err := openPackfile(idxPath, s.getFromPacks)
+		if err != nil {
+			continue
+		}
+
+		s.packs = append(s.packs, p)
+	}
+}
+
+func (s *ObjectStorage) New() core.Object {
+	return &core.RAWObject{}
+}
+
+// HashAlgorithm reports core.SHA1: the on-disk loose/pack layout this
+// package reads and writes doesn't yet support the SHA256 object-format
+// extension.
+func (s *ObjectStorage) HashAlgorithm() core.HashAlgorithm {
+	return core.SHA1
+}
+
+// Set writes obj as a loose object under dir, unless an object with the
+// same hash is already present, loose or packed. It returns the computed
+// hash.
+func (s *ObjectStorage) Set(obj core.Object) core.Hash {
+	h := obj.Hash()
+	if s.hasLoose(h) || s.hasPacked(h) {
+		return h
+	}
+
+	if err := s.writeLoose(h, obj); err != nil {
+		return h
+	}
+
+	s.mu.Lock()
+	s.looseCount++
+	shouldRepack := s.looseCount >= repackThreshold
+	s.mu.Unlock()
+
+	if shouldRepack {
+		s.Repack()
+	}
+
+	return h
+}
+
+// Get looks up h among the loose objects first, falling back to walking
+// the loaded pack indexes. It returns false if h is not known to this
+// storage.
+func (s *ObjectStorage) Get(h core.Hash) (core.Object, bool) {
+	if obj, ok := s.getLoose(h); ok {
+		return obj, true
+	}
+
+	return s.getFromPacks(h)
+}
+
+// hasPacked reports whether h is present in any pack already loaded by
+// this storage.
+func (s *ObjectStorage) hasPacked(h core.Hash) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, p := range s.packs {
+		if _, ok := p.idx.find(h); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *ObjectStorage) getFromPacks(h core.Hash) (core.Object, bool) {
+	s.mu.RLock()
+	packs := s.packs
+	s.mu.RUnlock()
+
+	for _, p := range packs {
+		if t, content, ok := p.get(h); ok {
+			obj := s.New()
+			obj.SetType(t)
+			obj.SetSize(int64(len(content)))
+
+			w := obj.Writer()
+			_, copyErr := io.Copy(w, bytes.NewReader(content))
+			closeErr := w.Close()
+			if copyErr != nil || closeErr != nil {
+				return nil, false
+			}
+			return obj, true
+		}
+	}
+
+	return nil, false
+}
+
+// Iter streams objects of the given type from both loose storage and the
+// loaded packs, each hash reported at most once even if it happens to
+// exist in both forms. Only the hashes are held in memory; each object is
+// read from disk lazily as the iterator advances.
+func (s *ObjectStorage) Iter(t core.ObjectType) core.ObjectIter {
+	seen := make(map[core.Hash]struct{})
+	var hashes []core.Hash
+
+	addHashes := func(hs []core.Hash) {
+		for _, h := range hs {
+			if _, ok := seen[h]; ok {
+				continue
+			}
+			seen[h] = struct{}{}
+			hashes = append(hashes, h)
+		}
+	}
+
+	addHashes(s.looseHashesOfType(t))
+
+	s.mu.RLock()
+	packs := s.packs
+	s.mu.RUnlock()
+
+	for _, p := range packs {
+		addHashes(p.hashesOfType(t))
+	}
+
+	return core.NewObjectLookupIter(s, hashes)
+}
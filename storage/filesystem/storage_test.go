@@ -0,0 +1,55 @@
+package filesystem
+
+import (
+	"io"
+	"testing"
+
+	"github.com/kimh/go-git/core"
+)
+
+func TestSetAfterRepackDoesNotDuplicateLoose(t *testing.T) {
+	s := newTestObjectStorage(t)
+
+	a := newTestBlob("aaaaa")
+	h := s.Set(a)
+
+	if err := s.Repack(); err != nil {
+		t.Fatalf("Repack() error: %v", err)
+	}
+	if s.hasLoose(h) {
+		t.Fatal("object is still loose after Repack")
+	}
+	if !s.hasPacked(h) {
+		t.Fatal("object is not reported as packed after Repack")
+	}
+
+	// Re-Set of content already packed (a re-push or re-clone of an
+	// object the server already has) must not write a second, loose copy.
+	s.Set(newTestBlob("aaaaa"))
+
+	if s.hasLoose(h) {
+		t.Error("Set wrote a loose copy of an object that was already packed")
+	}
+
+	count := countIter(t, s.Iter(core.BlobObject))
+	if count != 1 {
+		t.Errorf("Iter(BlobObject) yielded %d entries, want 1", count)
+	}
+}
+
+func countIter(t *testing.T, iter core.ObjectIter) int {
+	t.Helper()
+	defer iter.Close()
+
+	n := 0
+	for {
+		_, err := iter.Next()
+		if err == io.EOF {
+			return n
+		}
+		if err != nil {
+			t.Fatalf("Next() error: %v", err)
+		}
+		n++
+	}
+}
@@ -0,0 +1,119 @@
+package filesystem
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/kimh/go-git/core"
+)
+
+// Begin returns a Tx that stages objects in memory. Commit installs all of
+// them in a single irrevocable step: it builds one pack + index pair in a
+// scratch directory and then renames both into objects/pack/, the same
+// two-file install git itself performs when it receives a pack. Nothing
+// staged is visible to Get/Iter until that install succeeds, so a failure
+// at any point before it leaves the storage exactly as it was.
+func (s *ObjectStorage) Begin() core.Tx {
+	return &tx{storage: s, staged: make(map[core.Hash]core.Object)}
+}
+
+type tx struct {
+	storage *ObjectStorage
+	staged  map[core.Hash]core.Object
+	order   []core.Hash
+	done    bool
+}
+
+func (t *tx) Set(obj core.Object) core.Hash {
+	h := obj.Hash()
+
+	if _, ok := t.staged[h]; !ok {
+		if !t.storage.hasLoose(h) {
+			t.order = append(t.order, h)
+		}
+		t.staged[h] = obj
+	}
+
+	return h
+}
+
+func (t *tx) Get(h core.Hash) (core.Object, bool) {
+	if obj, ok := t.staged[h]; ok {
+		return obj, true
+	}
+	return t.storage.Get(h)
+}
+
+// Commit packs every staged object into one pack + index pair and installs
+// it with two renames: the pack is moved into place first, but it has no
+// meaning without its index, so until the index rename also succeeds,
+// loadPacks has nothing new to find and Get/Iter keep returning what they
+// did before Commit ran.
+func (t *tx) Commit() error {
+	if t.done {
+		return nil
+	}
+	t.done = true
+
+	if len(t.order) == 0 {
+		return nil
+	}
+
+	objs := make([]core.Object, len(t.order))
+	for i, h := range t.order {
+		objs[i] = t.staged[h]
+	}
+
+	packDir := filepath.Join(t.storage.dir, "pack")
+	if err := os.MkdirAll(packDir, 0755); err != nil {
+		return err
+	}
+
+	scratch, err := ioutil.TempDir(packDir, "tx-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(scratch)
+
+	scratchPack := filepath.Join(scratch, "pack.pack")
+	scratchIdx := filepath.Join(scratch, "pack.idx")
+
+	offsets, err := writePackObjects(scratchPack, objs)
+	if err != nil {
+		return err
+	}
+	if err := writePackIndex(scratchIdx, offsets); err != nil {
+		return err
+	}
+
+	base := filepath.Join(packDir, fmt.Sprintf("pack-%x", sha1.Sum([]byte(fmt.Sprint(t.order)))))
+	packPath := base + ".pack"
+	idxPath := base + ".idx"
+
+	if err := os.Rename(scratchPack, packPath); err != nil {
+		return err
+	}
+	if err := os.Rename(scratchIdx, idxPath); err != nil {
+		os.Remove(packPath)
+		return err
+	}
+
+	p, err := openPackfile(idxPath, t.storage.getFromPacks)
+	if err != nil {
+		return err
+	}
+
+	t.storage.mu.Lock()
+	t.storage.packs = append(t.storage.packs, p)
+	t.storage.mu.Unlock()
+
+	return nil
+}
+
+func (t *tx) Rollback() error {
+	t.done = true
+	return nil
+}
@@ -0,0 +1,108 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kimh/go-git/core"
+)
+
+func newTestObjectStorage(t *testing.T) *ObjectStorage {
+	dir, err := ioutil.TempDir("", "gogit-tx-test")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return NewObjectStorage(dir)
+}
+
+func newTestBlob(content string) core.Object {
+	obj := &core.RAWObject{}
+	obj.SetType(core.BlobObject)
+	obj.SetSize(int64(len(content)))
+	w := obj.Writer()
+	w.Write([]byte(content))
+	w.Close()
+	return obj
+}
+
+func TestTxCommitInstallsAllStagedObjects(t *testing.T) {
+	s := newTestObjectStorage(t)
+
+	a := newTestBlob("aaaaa")
+	b := newTestBlob("bbbbb")
+
+	tx := s.Begin()
+	tx.Set(a)
+	tx.Set(b)
+
+	if _, ok := s.Get(a.Hash()); ok {
+		t.Fatal("a is visible through the storage before Commit")
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() error: %v", err)
+	}
+
+	for _, want := range []core.Object{a, b} {
+		got, ok := s.Get(want.Hash())
+		if !ok {
+			t.Errorf("Get(%v) = false after Commit, want true", want.Hash())
+			continue
+		}
+		if got.Hash() != want.Hash() {
+			t.Errorf("Get(%v).Hash() = %v, want %v", want.Hash(), got.Hash(), want.Hash())
+		}
+	}
+}
+
+func TestTxRollbackLeavesStorageUntouched(t *testing.T) {
+	s := newTestObjectStorage(t)
+
+	a := newTestBlob("aaaaa")
+
+	tx := s.Begin()
+	tx.Set(a)
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback() error: %v", err)
+	}
+
+	if _, ok := s.Get(a.Hash()); ok {
+		t.Error("a is visible through the storage after Rollback")
+	}
+}
+
+func TestTxGetSeesStagedObjectsBeforeCommit(t *testing.T) {
+	s := newTestObjectStorage(t)
+
+	a := newTestBlob("aaaaa")
+
+	tx := s.Begin()
+	tx.Set(a)
+
+	got, ok := tx.Get(a.Hash())
+	if !ok {
+		t.Fatal("tx.Get(a) = false before Commit, want true")
+	}
+	if got.Hash() != a.Hash() {
+		t.Errorf("tx.Get(a).Hash() = %v, want %v", got.Hash(), a.Hash())
+	}
+}
+
+func TestTxCommitIsIdempotent(t *testing.T) {
+	s := newTestObjectStorage(t)
+
+	a := newTestBlob("aaaaa")
+
+	tx := s.Begin()
+	tx.Set(a)
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("first Commit() error: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("second Commit() error: %v", err)
+	}
+}